@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEventIterator_PaginatesAcrossPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("nextPageKey") {
+		case "":
+			w.Write([]byte(`{"events":[{},{}],"nextPageKey":"1"}`))
+		case "1":
+			w.Write([]byte(`{"events":[{}],"nextPageKey":"0"}`))
+		default:
+			t.Fatalf("unexpected nextPageKey %q", r.URL.Query().Get("nextPageKey"))
+		}
+	}))
+	defer server.Close()
+
+	h := NewEventHandler(server.URL)
+	it, err := h.StreamEvents(&EventFilter{})
+	if err != nil {
+		t.Fatalf("StreamEvents() error = %v", err)
+	}
+	defer it.Close()
+
+	got := 0
+	for {
+		_, ok, eerr := it.Next(context.Background())
+		if eerr != nil {
+			t.Fatalf("Next() error = %v", eerr)
+		}
+		if !ok {
+			break
+		}
+		got++
+	}
+
+	if got != 3 {
+		t.Errorf("got %d events across pages, want 3", got)
+	}
+}
+
+func TestEventIterator_StopsAfterClose(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"events":[{},{}],"nextPageKey":"0"}`))
+	}))
+	defer server.Close()
+
+	h := NewEventHandler(server.URL)
+	it, err := h.StreamEvents(&EventFilter{})
+	if err != nil {
+		t.Fatalf("StreamEvents() error = %v", err)
+	}
+
+	it.Close()
+
+	_, ok, eerr := it.Next(context.Background())
+	if eerr != nil || ok {
+		t.Errorf("Next() after Close() = (_, %v, %v), want (_, false, nil)", ok, eerr)
+	}
+}
+
+func TestEventIterator_StopsAtNumberOfPages(t *testing.T) {
+	var pagesServed int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := atomic.AddInt32(&pagesServed, 1) - 1
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"events":[{}],"nextPageKey":"` + strconv.Itoa(int(page)+1) + `"}`))
+	}))
+	defer server.Close()
+
+	h := NewEventHandler(server.URL)
+	events, err := h.GetEvents(&EventFilter{NumberOfPages: 1})
+	if err != nil {
+		t.Fatalf("GetEvents() error = %v", err)
+	}
+
+	if len(events) != 1 {
+		t.Errorf("got %d events, want 1 (cut off after the first page)", len(events))
+	}
+	if got := atomic.LoadInt32(&pagesServed); got != 1 {
+		t.Errorf("server served %d pages, want exactly 1", got)
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/keptn/go-utils/pkg/api/models"
+)
+
+func TestSendEvents_PartialFailureReturnsSucceededIDsAndFirstError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"id":"event-1"},{"error":{"message":"validation failed"}},{"id":"event-3"}]`))
+	}))
+	defer server.Close()
+
+	h := NewEventHandler(server.URL)
+	ids, err := h.SendEvents([]*models.KeptnContextExtendedCE{{}, {}, {}})
+
+	if len(ids) != 2 || ids[0] != "event-1" || ids[1] != "event-3" {
+		t.Errorf("SendEvents() ids = %v, want [event-1 event-3]", ids)
+	}
+	if err == nil {
+		t.Error("SendEvents() error = nil, want the first per-event failure surfaced")
+	}
+}
+
+func TestSendEvents_AllSucceed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"id":"event-1"}]`))
+	}))
+	defer server.Close()
+
+	h := NewEventHandler(server.URL)
+	ids, err := h.SendEvents([]*models.KeptnContextExtendedCE{{}})
+	if err != nil {
+		t.Fatalf("SendEvents() error = %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "event-1" {
+		t.Errorf("SendEvents() ids = %v, want [event-1]", ids)
+	}
+}
+
+func TestCancelEventsByContext(t *testing.T) {
+	var gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, r.ContentLength)
+		r.Body.Read(buf)
+		gotBody = string(buf)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	h := NewEventHandler(server.URL)
+	if err := h.CancelEventsByContext("my-keptn-context"); err != nil {
+		t.Fatalf("CancelEventsByContext() error = %v", err)
+	}
+	if want := `{"keptnContext":"my-keptn-context"}`; gotBody != want {
+		t.Errorf("request body = %q, want %q", gotBody, want)
+	}
+}
+
+func TestCancelEventsByContext_PropagatesServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message":"unknown keptnContext"}`))
+	}))
+	defer server.Close()
+
+	h := NewEventHandler(server.URL)
+	if err := h.CancelEventsByContext("nonexistent"); err == nil {
+		t.Error("CancelEventsByContext() error = nil, want the server's error surfaced")
+	}
+}
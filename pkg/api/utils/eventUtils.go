@@ -1,24 +1,290 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"io/ioutil"
-	"log"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/keptn/go-utils/pkg/api/models"
 )
 
 // EventHandler handles services
 type EventHandler struct {
-	BaseURL    string
-	AuthToken  string
-	AuthHeader string
-	HTTPClient *http.Client
-	Scheme     string
+	BaseURL           string
+	AuthToken         string
+	AuthHeader        string
+	HTTPClient        *http.Client
+	Scheme            string
+	RetryPolicy       RetryPolicy
+	UserAgent         string
+	AdditionalHeaders http.Header
+
+	deadline requestDeadline
+}
+
+// libraryVersion is substituted into the default User-Agent sent by an EventHandler.
+const libraryVersion = "develop"
+
+// defaultUserAgent is the User-Agent an EventHandler sends unless UserAgent is set
+// explicitly, so server-side logs and metrics can attribute traffic per client.
+const defaultUserAgent = "keptn-go-utils/" + libraryVersion
+
+// setRequestHeaders applies the handler's UserAgent and AdditionalHeaders to req,
+// alongside the existing auth header set by addAuthHeader.
+func setRequestHeaders(req *http.Request, e *EventHandler) {
+	userAgent := e.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	for key, values := range e.AdditionalHeaders {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+}
+
+// RequestHook is invoked by the transport returned by NewInstrumentedTransport after
+// each round trip, so callers can plug in tracing or metrics (e.g. an OpenTelemetry span,
+// or a Prometheus counter keyed by event type) without reimplementing the client.
+type RequestHook func(req *http.Request, resp *http.Response, err error)
+
+// instrumentedTransport decorates an http.RoundTripper, invoking a set of RequestHooks
+// after every round trip it performs.
+type instrumentedTransport struct {
+	base  http.RoundTripper
+	hooks []RequestHook
+}
+
+// NewInstrumentedTransport returns an http.RoundTripper that delegates to base and then
+// invokes each of hooks with the request, response and error from the round trip. A nil
+// base defaults to http.DefaultTransport.
+func NewInstrumentedTransport(base http.RoundTripper, hooks ...RequestHook) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &instrumentedTransport{base: base, hooks: hooks}
+}
+
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	for _, hook := range t.hooks {
+		hook(req, resp, err)
+	}
+	return resp, err
+}
+
+// getClientTransport returns the http.RoundTripper that the EventHandler constructors install
+// on their http.Client: http.DefaultTransport wrapped by NewInstrumentedTransport with no hooks.
+func getClientTransport() http.RoundTripper {
+	return NewInstrumentedTransport(http.DefaultTransport)
+}
+
+// RetryPolicy configures exponential backoff with jitter for requests made through an EventHandler
+type RetryPolicy struct {
+	MaxAttempts        int
+	InitialInterval    time.Duration
+	MaxInterval        time.Duration
+	Multiplier         float64
+	RetryNonIdempotent bool
+	ShouldRetry        func(resp *http.Response, err error) bool
+}
+
+// defaultRetryPolicy gives a few quick retries for transient failures without letting a
+// single paginated fetch stall for too long.
+func defaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:     5,
+		InitialInterval: 10 * time.Millisecond,
+		MaxInterval:     10 * time.Second,
+		Multiplier:      1.6,
+	}
+}
+
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// shouldRetry decides whether resp/err warrants another attempt, deferring to
+// RetryPolicy.ShouldRetry when the caller has set one.
+func (p RetryPolicy) shouldRetry(resp *http.Response, err error) bool {
+	if p.ShouldRetry != nil {
+		return p.ShouldRetry(resp, err)
+	}
+	if err != nil {
+		return true
+	}
+	if resp == nil {
+		return false
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode >= 500
+}
+
+// retryAfter extracts a retry delay from a Retry-After header, which may be either a
+// number of seconds or an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	ra := resp.Header.Get("Retry-After")
+	if ra == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(ra); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(ra); err == nil {
+		return time.Until(t), true
+	}
+	return 0, false
+}
+
+// doWithRetry performs req via e.HTTPClient, retrying according to e.RetryPolicy (or
+// defaultRetryPolicy if unset). The retry loop is context-aware: it stops as soon as ctx
+// is done, whether that happens mid-request or while waiting out a backoff interval.
+func (e *EventHandler) doWithRetry(ctx context.Context, req *http.Request) (*http.Response, error) {
+	policy := e.RetryPolicy
+	if policy.MaxAttempts <= 0 {
+		policy = defaultRetryPolicy()
+	}
+	canRetry := policy.RetryNonIdempotent || isIdempotentMethod(req.Method)
+	interval := policy.InitialInterval
+
+	for attempt := 1; ; attempt++ {
+		resp, err := e.HTTPClient.Do(req)
+
+		if !canRetry || attempt >= policy.MaxAttempts || !policy.shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		wait := interval
+		if d, ok := retryAfter(resp); ok {
+			wait = d
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if wait > 0 {
+			wait += time.Duration(rand.Int63n(int64(wait)/2 + 1))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+
+		interval = time.Duration(float64(interval) * policy.Multiplier)
+		if interval > policy.MaxInterval {
+			interval = policy.MaxInterval
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// requestDeadline provides a resettable deadline for in-flight requests: a single
+// cancellation channel is shared by callers waiting on it, and an AfterFunc timer closes
+// that channel once the deadline elapses. SetRequestTimeout resets an in-flight fetch by
+// replacing the channel and re-arming the timer.
+type requestDeadline struct {
+	mu       sync.Mutex
+	timer    *time.Timer
+	cancelCh chan struct{}
+}
+
+// channel returns the current cancellation channel, creating one if none
+// has been armed yet.
+func (d *requestDeadline) channel() chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cancelCh == nil {
+		d.cancelCh = make(chan struct{})
+	}
+	return d.cancelCh
+}
+
+// set arms a timer that closes the cancellation channel once t elapses,
+// replacing any previously armed timer and channel. Anyone already watching
+// the previous channel (e.g. a request that started before this call) is
+// cancelled immediately, not just requests started after. A zero t disarms
+// the deadline.
+func (d *requestDeadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil {
+		if d.timer.Stop() {
+			close(d.cancelCh)
+		}
+	} else if d.cancelCh != nil {
+		select {
+		case <-d.cancelCh:
+		default:
+			close(d.cancelCh)
+		}
+	}
+
+	d.cancelCh = make(chan struct{})
+	d.timer = nil
+
+	if t.IsZero() {
+		return
+	}
+
+	cancelCh := d.cancelCh
+	dur := time.Until(t)
+	if dur <= 0 {
+		close(cancelCh)
+		return
+	}
+	d.timer = time.AfterFunc(dur, func() {
+		close(cancelCh)
+	})
+}
+
+// SetRequestTimeout aborts any request currently in flight on this handler
+// and arms a deadline at t for every request started before the deadline is
+// next reset. Passing the zero time disarms the deadline for later requests;
+// requests already in flight are still aborted.
+func (e *EventHandler) SetRequestTimeout(t time.Time) {
+	e.deadline.set(t)
+}
+
+// withDeadline returns a context derived from ctx that is also cancelled
+// when the handler's deadline elapses, along with a cancel func the caller
+// must invoke once the request is done to release the watcher goroutine.
+func (e *EventHandler) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	cancelCh := e.deadline.channel()
+	derived, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-cancelCh:
+			cancel()
+		case <-derived.Done():
+		}
+	}()
+	return derived, cancel
 }
 
 // EventFilter allows to filter events based on the provided properties
@@ -41,11 +307,12 @@ func NewEventHandler(baseURL string) *EventHandler {
 		baseURL = strings.TrimPrefix(baseURL, "http://")
 	}
 	return &EventHandler{
-		BaseURL:    baseURL,
-		AuthHeader: "",
-		AuthToken:  "",
-		HTTPClient: &http.Client{Transport: getClientTransport()},
-		Scheme:     "http",
+		BaseURL:     baseURL,
+		AuthHeader:  "",
+		AuthToken:   "",
+		HTTPClient:  &http.Client{Transport: getClientTransport()},
+		Scheme:      "http",
+		RetryPolicy: defaultRetryPolicy(),
 	}
 }
 
@@ -66,11 +333,12 @@ func NewAuthenticatedEventHandler(baseURL string, authToken string, authHeader s
 	}
 
 	return &EventHandler{
-		BaseURL:    baseURL,
-		AuthHeader: authHeader,
-		AuthToken:  authToken,
-		HTTPClient: httpClient,
-		Scheme:     scheme,
+		BaseURL:     baseURL,
+		AuthHeader:  authHeader,
+		AuthToken:   authToken,
+		HTTPClient:  httpClient,
+		Scheme:      scheme,
+		RetryPolicy: defaultRetryPolicy(),
 	}
 }
 
@@ -92,10 +360,27 @@ func (e *EventHandler) getHTTPClient() *http.Client {
 
 // GetEvents returns all events matching the properties in the passed filter object
 func (e *EventHandler) GetEvents(filter *EventFilter) ([]*models.KeptnContextExtendedCE, *models.Error) {
+	return e.GetEventsWithContext(context.Background(), filter)
+}
 
+// GetEventsWithContext returns all events matching the properties in the passed filter object.
+// The provided ctx governs the whole paginated fetch: if it is cancelled or its deadline
+// elapses, the fetch is aborted as soon as the in-flight request notices.
+func (e *EventHandler) GetEventsWithContext(ctx context.Context, filter *EventFilter) ([]*models.KeptnContextExtendedCE, *models.Error) {
+	it, err := e.StreamEvents(filter)
+	if err != nil {
+		return nil, buildErrorResponse(err.Error())
+	}
+	defer it.Close()
+
+	return it.AllEvents(ctx)
+}
+
+// buildEventsURI builds the (unpaginated) /event request URI for the given filter.
+func (e *EventHandler) buildEventsURI(filter *EventFilter) (string, error) {
 	u, err := url.Parse(e.Scheme + "://" + e.getBaseURL() + "/event?")
 	if err != nil {
-		log.Fatal("error parsing url")
+		return "", err
 	}
 
 	query := u.Query()
@@ -124,66 +409,274 @@ func (e *EventHandler) GetEvents(filter *EventFilter) ([]*models.KeptnContextExt
 
 	u.RawQuery = query.Encode()
 
-	return e.getEvents(u.String(), filter.NumberOfPages)
+	return u.String(), nil
 }
 
-func (e *EventHandler) getEvents(uri string, numberOfPages int) ([]*models.KeptnContextExtendedCE, *models.Error) {
-	events := []*models.KeptnContextExtendedCE{}
-	nextPageKey := ""
+// StreamEvents returns an EventIterator over all events matching the properties in the
+// passed filter object. Unlike GetEvents, it fetches one page at a time instead of
+// buffering the whole result set, so long backfills run with bounded memory.
+func (e *EventHandler) StreamEvents(filter *EventFilter) (*EventIterator, error) {
+	uri, err := e.buildEventsURI(filter)
+	if err != nil {
+		return nil, err
+	}
 
-	for {
-		url, err := url.Parse(uri)
+	return &EventIterator{
+		handler:       e,
+		uri:           uri,
+		numberOfPages: filter.NumberOfPages,
+	}, nil
+}
+
+// EventIterator streams the events matching a filter one page at a time, fetching the
+// next page transparently once the current one is exhausted. Callers that want to stop
+// early should call Close once they are done with the iterator.
+type EventIterator struct {
+	handler       *EventHandler
+	uri           string
+	numberOfPages int
+
+	nextPageKey string
+	page        []*models.KeptnContextExtendedCE
+	pageIdx     int
+	donePaging  bool
+	closed      bool
+}
+
+// Next returns the next event, or false as its second return value once the iterator is
+// exhausted. It transparently fetches the next page via nextPageKey when the current page
+// has been handed out in full. The provided ctx governs any page fetch triggered by this call.
+func (it *EventIterator) Next(ctx context.Context) (*models.KeptnContextExtendedCE, bool, *models.Error) {
+	if it.closed {
+		return nil, false, nil
+	}
+
+	for it.pageIdx >= len(it.page) {
+		if it.donePaging {
+			return nil, false, nil
+		}
+
+		received, err := it.handler.fetchEventsPage(ctx, it.uri, it.nextPageKey)
 		if err != nil {
-			return nil, buildErrorResponse(err.Error())
+			return nil, false, err
 		}
-		q := url.Query()
-		if nextPageKey != "" {
-			q.Set("nextPageKey", nextPageKey)
-			url.RawQuery = q.Encode()
+
+		it.page = received.Events
+		it.pageIdx = 0
+
+		if received.NextPageKey == "" || received.NextPageKey == "0" {
+			it.donePaging = true
+		} else {
+			nextPageKeyInt, _ := strconv.Atoi(received.NextPageKey)
+			if it.numberOfPages > 0 && nextPageKeyInt >= it.numberOfPages {
+				it.donePaging = true
+			}
+			it.nextPageKey = received.NextPageKey
 		}
-		req, err := http.NewRequest("GET", url.String(), nil)
-		req.Header.Set("Content-Type", "application/json")
-		addAuthHeader(req, e)
+	}
 
-		resp, err := e.HTTPClient.Do(req)
+	event := it.page[it.pageIdx]
+	it.pageIdx++
+	return event, true, nil
+}
+
+// Close releases the iterator. Any subsequent call to Next returns immediately with no event.
+func (it *EventIterator) Close() {
+	it.closed = true
+}
+
+// AllEvents drains the iterator into a slice. It is a convenience for callers that do want
+// the whole result set in memory, equivalent to what GetEvents used to do directly.
+func (it *EventIterator) AllEvents(ctx context.Context) ([]*models.KeptnContextExtendedCE, *models.Error) {
+	events := []*models.KeptnContextExtendedCE{}
+	for {
+		event, ok, err := it.Next(ctx)
 		if err != nil {
-			return nil, buildErrorResponse(err.Error())
+			return nil, err
 		}
-		defer resp.Body.Close()
+		if !ok {
+			break
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
 
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
+// fetchEventsPage fetches a single page of the /event listing at uri, setting
+// nextPageKey as a query parameter when non-empty.
+func (e *EventHandler) fetchEventsPage(ctx context.Context, uri string, nextPageKey string) (*models.Events, *models.Error) {
+	select {
+	case <-ctx.Done():
+		return nil, buildErrorResponse(ctx.Err().Error())
+	default:
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, buildErrorResponse(err.Error())
+	}
+	if nextPageKey != "" {
+		q := u.Query()
+		q.Set("nextPageKey", nextPageKey)
+		u.RawQuery = q.Encode()
+	}
+
+	reqCtx, cancel := e.withDeadline(ctx)
+	defer cancel()
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, buildErrorResponse(err.Error())
+	}
+	req = req.WithContext(reqCtx)
+	req.Header.Set("Content-Type", "application/json")
+	addAuthHeader(req, e)
+	setRequestHeaders(req, e)
+
+	resp, err := e.doWithRetry(reqCtx, req)
+	if err != nil {
+		if reqErr := reqCtx.Err(); reqErr != nil {
+			return nil, buildErrorResponse(reqErr.Error())
+		}
+		return nil, buildErrorResponse(err.Error())
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, buildErrorResponse(err.Error())
+	}
+
+	if resp.StatusCode != 200 {
+		var respErr models.Error
+		if err := json.Unmarshal(body, &respErr); err != nil {
 			return nil, buildErrorResponse(err.Error())
 		}
+		return nil, &respErr
+	}
 
-		if resp.StatusCode == 200 {
-			received := &models.Events{}
-			err = json.Unmarshal(body, received)
-			if err != nil {
-				return nil, buildErrorResponse(err.Error())
-			}
-			events = append(events, received.Events...)
+	received := &models.Events{}
+	if err := json.Unmarshal(body, received); err != nil {
+		return nil, buildErrorResponse(err.Error())
+	}
 
-			if received.NextPageKey == "" || received.NextPageKey == "0" {
-				break
-			}
+	return received, nil
+}
 
-			nextPageKeyInt, _ := strconv.Atoi(received.NextPageKey)
+const bulkEventsPath = "/event/batch"
 
-			if numberOfPages > 0 && nextPageKeyInt >= numberOfPages {
-				break
-			}
+// eventResult is the per-event outcome of a bulk SendEvents call: either the ID the
+// datastore assigned to the stored event, or the error encountered trying to store it.
+type eventResult struct {
+	ID    string        `json:"id,omitempty"`
+	Error *models.Error `json:"error,omitempty"`
+}
 
-			nextPageKey = received.NextPageKey
-		} else {
-			var respErr models.Error
-			err = json.Unmarshal(body, &respErr)
-			if err != nil {
-				return nil, buildErrorResponse(err.Error())
+// SendEvents submits a batch of CloudEvents to the datastore in a single call, returning
+// the IDs of the events that were stored. Storing is not all-or-nothing: an event that
+// failed is omitted from the returned IDs rather than aborting the rest of the batch, and
+// the first such failure is returned as the error so the caller can tell the batch was
+// only partially accepted.
+func (e *EventHandler) SendEvents(events []*models.KeptnContextExtendedCE) ([]string, *models.Error) {
+	reqBody, err := json.Marshal(events)
+	if err != nil {
+		return nil, buildErrorResponse(err.Error())
+	}
+
+	reqCtx, cancel := e.withDeadline(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequest("POST", e.Scheme+"://"+e.getBaseURL()+bulkEventsPath, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, buildErrorResponse(err.Error())
+	}
+	req = req.WithContext(reqCtx)
+	req.Header.Set("Content-Type", "application/json")
+	addAuthHeader(req, e)
+	setRequestHeaders(req, e)
+
+	resp, err := e.doWithRetry(reqCtx, req)
+	if err != nil {
+		return nil, buildErrorResponse(err.Error())
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, buildErrorResponse(err.Error())
+	}
+
+	if resp.StatusCode != 200 {
+		var respErr models.Error
+		if err := json.Unmarshal(respBody, &respErr); err != nil {
+			return nil, buildErrorResponse(err.Error())
+		}
+		return nil, &respErr
+	}
+
+	var results []eventResult
+	if err := json.Unmarshal(respBody, &results); err != nil {
+		return nil, buildErrorResponse(err.Error())
+	}
+
+	ids := make([]string, 0, len(results))
+	var firstErr *models.Error
+	for _, r := range results {
+		if r.Error != nil {
+			if firstErr == nil {
+				firstErr = r.Error
 			}
-			return nil, &respErr
+			continue
 		}
+		ids = append(ids, r.ID)
 	}
 
-	return events, nil
+	return ids, firstErr
+}
+
+const cancelEventsPath = "/event/cancel"
+
+// cancelEventsRequest is the payload for CancelEventsByContext.
+type cancelEventsRequest struct {
+	KeptnContext string `json:"keptnContext"`
+}
+
+// CancelEventsByContext aborts in-flight sequences associated with keptnContext
+func (e *EventHandler) CancelEventsByContext(keptnContext string) *models.Error {
+	reqBody, err := json.Marshal(cancelEventsRequest{KeptnContext: keptnContext})
+	if err != nil {
+		return buildErrorResponse(err.Error())
+	}
+
+	reqCtx, cancel := e.withDeadline(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequest("POST", e.Scheme+"://"+e.getBaseURL()+cancelEventsPath, bytes.NewReader(reqBody))
+	if err != nil {
+		return buildErrorResponse(err.Error())
+	}
+	req = req.WithContext(reqCtx)
+	req.Header.Set("Content-Type", "application/json")
+	addAuthHeader(req, e)
+	setRequestHeaders(req, e)
+
+	resp, err := e.doWithRetry(reqCtx, req)
+	if err != nil {
+		return buildErrorResponse(err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 200 || resp.StatusCode == 204 {
+		return nil
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return buildErrorResponse(err.Error())
+	}
+
+	var respErr models.Error
+	if err := json.Unmarshal(respBody, &respErr); err != nil {
+		return buildErrorResponse(err.Error())
+	}
+	return &respErr
 }
\ No newline at end of file
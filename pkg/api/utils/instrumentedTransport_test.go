@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewInstrumentedTransport_InvokesHooksWithRequestAndResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+
+	var gotReq *http.Request
+	var gotResp *http.Response
+	var gotErr error
+	hook := func(req *http.Request, resp *http.Response, err error) {
+		gotReq, gotResp, gotErr = req, resp, err
+	}
+
+	client := &http.Client{Transport: NewInstrumentedTransport(http.DefaultTransport, hook)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("client.Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if gotReq == nil {
+		t.Error("hook was not invoked with the request")
+	}
+	if gotErr != nil {
+		t.Errorf("hook err = %v, want nil", gotErr)
+	}
+	if gotResp == nil || gotResp.StatusCode != http.StatusTeapot {
+		t.Errorf("hook resp = %v, want status %d", gotResp, http.StatusTeapot)
+	}
+}
+
+func TestSetRequestHeaders_AppliesUserAgentAndAdditionalHeaders(t *testing.T) {
+	var gotUserAgent, gotCustomHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUserAgent = r.Header.Get("User-Agent")
+		gotCustomHeader = r.Header.Get("X-Custom")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"events":[],"nextPageKey":"0"}`))
+	}))
+	defer server.Close()
+
+	h := NewEventHandler(server.URL)
+	h.UserAgent = "my-client/1.0"
+	h.AdditionalHeaders = http.Header{"X-Custom": []string{"value"}}
+
+	if _, err := h.GetEvents(&EventFilter{}); err != nil {
+		t.Fatalf("GetEvents() error = %v", err)
+	}
+
+	if gotUserAgent != "my-client/1.0" {
+		t.Errorf("User-Agent = %q, want %q", gotUserAgent, "my-client/1.0")
+	}
+	if gotCustomHeader != "value" {
+		t.Errorf("X-Custom = %q, want %q", gotCustomHeader, "value")
+	}
+}
+
+func TestSetRequestHeaders_DefaultsUserAgent(t *testing.T) {
+	var gotUserAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUserAgent = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"events":[],"nextPageKey":"0"}`))
+	}))
+	defer server.Close()
+
+	h := NewEventHandler(server.URL)
+	if _, err := h.GetEvents(&EventFilter{}); err != nil {
+		t.Fatalf("GetEvents() error = %v", err)
+	}
+
+	if gotUserAgent != defaultUserAgent {
+		t.Errorf("User-Agent = %q, want default %q", gotUserAgent, defaultUserAgent)
+	}
+}
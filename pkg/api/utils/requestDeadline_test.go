@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetEventsWithContext_AbortsOnCancelledContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"events":[{}],"nextPageKey":"0"}`))
+	}))
+	defer server.Close()
+
+	h := NewEventHandler(server.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := h.GetEventsWithContext(ctx, &EventFilter{}); err == nil {
+		t.Fatal("GetEventsWithContext() error = nil, want an error for an already-cancelled context")
+	}
+}
+
+func TestSetRequestTimeout_AbortsInFlightFetch(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"events":[{}],"nextPageKey":"0"}`))
+	}))
+	defer server.Close()
+	defer close(unblock)
+
+	h := NewEventHandler(server.URL)
+	h.SetRequestTimeout(time.Now().Add(50 * time.Millisecond))
+
+	start := time.Now()
+	_, err := h.GetEvents(&EventFilter{})
+	if err == nil {
+		t.Fatal("GetEvents() error = nil, want an error once the deadline elapses")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("GetEvents() took %s to abort, want it to return promptly after the deadline", elapsed)
+	}
+}
+
+func TestSetRequestTimeout_AbortsFetchAlreadyInFlight(t *testing.T) {
+	serverGotRequest := make(chan struct{})
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(serverGotRequest)
+		<-unblock
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"events":[{}],"nextPageKey":"0"}`))
+	}))
+	defer server.Close()
+	defer close(unblock)
+
+	h := NewEventHandler(server.URL)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := h.GetEvents(&EventFilter{})
+		done <- err
+	}()
+
+	<-serverGotRequest
+	h.SetRequestTimeout(time.Now().Add(10 * time.Millisecond))
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("GetEvents() error = nil, want an error once SetRequestTimeout aborts the already in-flight fetch")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("GetEvents() did not abort within 1s of SetRequestTimeout being called mid-flight")
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestEventHandler(serverURL string) *EventHandler {
+	h := NewEventHandler(serverURL)
+	h.RetryPolicy = RetryPolicy{
+		MaxAttempts:     3,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     5 * time.Millisecond,
+		Multiplier:      2,
+	}
+	return h
+}
+
+func TestDoWithRetry_RetriesOn5xxThenSucceeds(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"events":[{}],"nextPageKey":"0"}`))
+	}))
+	defer server.Close()
+
+	h := newTestEventHandler(server.URL)
+	events, err := h.GetEvents(&EventFilter{})
+	if err != nil {
+		t.Fatalf("GetEvents() error = %v", err)
+	}
+	if len(events) != 1 {
+		t.Errorf("got %d events, want 1", len(events))
+	}
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Errorf("server saw %d attempts, want 3 (2 failures + 1 success)", got)
+	}
+}
+
+func TestDoWithRetry_HonorsRetryAfter(t *testing.T) {
+	var attempts int32
+	var firstAttempt time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			firstAttempt = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if time.Since(firstAttempt) < 900*time.Millisecond {
+			t.Errorf("retry happened after %s, want to honor the 1s Retry-After", time.Since(firstAttempt))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"events":[{}],"nextPageKey":"0"}`))
+	}))
+	defer server.Close()
+
+	h := newTestEventHandler(server.URL)
+	if _, err := h.GetEvents(&EventFilter{}); err != nil {
+		t.Fatalf("GetEvents() error = %v", err)
+	}
+}
+
+func TestDoWithRetry_DoesNotRetryNonIdempotentByDefault(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	h := newTestEventHandler(server.URL)
+	if _, err := h.SendEvents(nil); err == nil {
+		t.Fatal("SendEvents() error = nil, want an error from the 503 response")
+	}
+	if got := atomic.LoadInt32(&attempts); got != 1 {
+		t.Errorf("server saw %d attempts, want exactly 1 (POST is not idempotent by default)", got)
+	}
+}
+
+func TestDoWithRetry_RetriesNonIdempotentWhenOptedIn(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	h := newTestEventHandler(server.URL)
+	h.RetryPolicy.RetryNonIdempotent = true
+
+	if _, err := h.SendEvents(nil); err != nil {
+		t.Fatalf("SendEvents() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&attempts); got != 2 {
+		t.Errorf("server saw %d attempts, want 2 (1 failure + 1 success)", got)
+	}
+}